@@ -0,0 +1,50 @@
+package fingerprints
+
+// builtinProvider ships a fixed set of common takeover fingerprints so the
+// tool is useful without shipping or fetching a fingerprints.json.
+type builtinProvider struct{}
+
+func (builtinProvider) Name() string { return "builtin" }
+
+func (builtinProvider) Load() ([]Fingerprint, error) {
+	return builtin, nil
+}
+
+var builtin = []Fingerprint{
+	{
+		Cname:    []string{"s3.amazonaws.com", "s3-website"},
+		Response: "The specified bucket does not exist",
+		Service:  "S3 Bucket",
+		Nxdomain: false,
+	},
+	{
+		Cname:    []string{"cloudfront.net"},
+		Response: "Bad request",
+		Service:  "CloudFront",
+		Nxdomain: false,
+	},
+	{
+		Cname:    []string{"elasticbeanstalk.com"},
+		Response: "NXDOMAIN",
+		Service:  "Elastic Beanstalk",
+		Nxdomain: true,
+	},
+	{
+		Cname:    []string{"herokuapp.com", "herokussl.com"},
+		Response: "No such app",
+		Service:  "Heroku",
+		Nxdomain: false,
+	},
+	{
+		Cname:    []string{"github.io"},
+		Response: "There isn't a GitHub Pages site here",
+		Service:  "GitHub Pages",
+		Nxdomain: false,
+	},
+	{
+		Cname:    []string{"azurewebsites.net", "cloudapp.net", "cloudapp.azure.com", "trafficmanager.net", "blob.core.windows.net"},
+		Response: "404 Web Site not found",
+		Service:  "Azure",
+		Nxdomain: false,
+	},
+}
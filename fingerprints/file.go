@@ -0,0 +1,33 @@
+package fingerprints
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// FileProvider loads fingerprints from a local JSON file, in the same shape
+// subjack's own fingerprints.json uses. Missing files are treated as "no
+// fingerprints here" rather than an error, so a FileProvider can be
+// registered speculatively (e.g. as the fallback cache path for a
+// URLProvider) without requiring the file to exist.
+type FileProvider struct {
+	Path string
+}
+
+func (f FileProvider) Name() string { return "file:" + f.Path }
+
+func (f FileProvider) Load() ([]Fingerprint, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fps []Fingerprint
+	if err := json.Unmarshal(data, &fps); err != nil {
+		return nil, err
+	}
+	return fps, nil
+}
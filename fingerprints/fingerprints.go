@@ -0,0 +1,71 @@
+// Package fingerprints loads subdomain-takeover fingerprints for
+// route53-subjack. Unlike reading ./fingerprints.json once per hosted zone,
+// every Provider is loaded exactly once per process and the results are
+// merged, so the tool works out of the box from its built-in fingerprints
+// and can be pointed at a local file, a remote feed, or a custom Provider
+// registered by the caller.
+package fingerprints
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Fingerprint identifies one takeover-able service by the CNAME targets it's
+// reached through and the response body (or NXDOMAIN) that confirms the
+// endpoint is unclaimed. It's the feed shape route53-subjack reads and
+// writes, in and out of process: subjack's own fingerprints.json uses the
+// same fields, but subjack doesn't export a way to match against a custom
+// set of them, so route53-subjack matches independently in checkRecordSet.
+type Fingerprint struct {
+	Service  string   `json:"service"`
+	Cname    []string `json:"cname"`
+	Response string   `json:"response"`
+	Nxdomain bool     `json:"nxdomain"`
+}
+
+// Provider supplies a set of takeover fingerprints.
+type Provider interface {
+	Name() string
+	Load() ([]Fingerprint, error)
+}
+
+var registry []Provider
+
+// Register adds a fingerprint provider to the default load order. Built-in
+// providers register themselves from init(); callers can Register their own
+// Provider (e.g. an internal service catalog) before calling Load.
+func Register(p Provider) {
+	registry = append(registry, p)
+}
+
+func init() {
+	Register(builtinProvider{})
+}
+
+var (
+	loadOnce sync.Once
+	cache    []Fingerprint
+)
+
+// Load runs every registered provider and merges their fingerprints. The
+// result is cached after the first call, so a scan touching many hosted
+// zones only pays for loading (and any remote fetch) once. route53-subjack
+// calls Load concurrently, one goroutine per hosted zone, so the population
+// is guarded by a sync.Once rather than a plain bool.
+func Load() []Fingerprint {
+	loadOnce.Do(func() {
+		var all []Fingerprint
+		for _, p := range registry {
+			fps, err := p.Load()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fingerprints: %s: %v\n", p.Name(), err)
+				continue
+			}
+			all = append(all, fps...)
+		}
+		cache = all
+	})
+	return cache
+}
@@ -0,0 +1,90 @@
+package fingerprints
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// URLProvider fetches a remote fingerprints feed, caching the response body
+// to CachePath and revalidating with ETag/If-Modified-Since on every Load so
+// a scan that runs on a schedule doesn't re-download an unchanged feed.
+type URLProvider struct {
+	URL       string
+	CachePath string
+}
+
+func (u URLProvider) Name() string { return "url:" + u.URL }
+
+type urlCacheMeta struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+}
+
+func (u URLProvider) metaPath() string { return u.CachePath + ".meta.json" }
+
+// UpdateCache fetches url and refreshes cachePath if the feed has changed
+// since the last fetch. It backs the `fingerprints update` subcommand.
+func UpdateCache(url, cachePath string) error {
+	return URLProvider{URL: url, CachePath: cachePath}.refresh()
+}
+
+func (u URLProvider) Load() ([]Fingerprint, error) {
+	if err := u.refresh(); err != nil {
+		// Fall back to whatever's cached locally; a transient fetch failure
+		// shouldn't take out a scan that was otherwise working yesterday.
+		fmt.Fprintf(os.Stderr, "fingerprints: %s: %v, using local cache\n", u.Name(), err)
+	}
+	return FileProvider{Path: u.CachePath}.Load()
+}
+
+// refresh conditionally re-fetches u.URL, only overwriting CachePath when the
+// server reports the feed has changed.
+func (u URLProvider) refresh() error {
+	req, err := http.NewRequest(http.MethodGet, u.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	var meta urlCacheMeta
+	if data, err := ioutil.ReadFile(u.metaPath()); err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var fps []Fingerprint
+	if err := json.Unmarshal(body, &fps); err != nil {
+		return fmt.Errorf("invalid fingerprints feed: %w", err)
+	}
+	if err := ioutil.WriteFile(u.CachePath, body, 0644); err != nil {
+		return err
+	}
+
+	meta = urlCacheMeta{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	metaBytes, _ := json.Marshal(meta)
+	return ioutil.WriteFile(u.metaPath(), metaBytes, 0644)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultRollbackLog is where apply appends one RollbackRecord per action,
+// written before the mutation it describes is performed.
+const defaultRollbackLog = "iam-rollback.log"
+
+// RollbackRecord captures the prior state of whatever an Action is about to
+// remove, so an operator can manually reconstruct it afterwards.
+type RollbackRecord struct {
+	Timestamp              time.Time  `json:"timestamp"`
+	Action                 ActionType `json:"action"`
+	Account                string     `json:"account"`
+	Username               string     `json:"username"`
+	AccessKeyID            string     `json:"access_key_id,omitempty"`
+	AccessKeyCreateDate    *time.Time `json:"access_key_create_date,omitempty"`
+	LoginProfileCreateDate *time.Time `json:"login_profile_create_date,omitempty"`
+}
+
+// runApplyCommand implements `iam-scanner apply <plan.json>`: it executes a
+// plan written by a prior -active scan, appending a rollback record before
+// every mutation.
+func runApplyCommand(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	interactive := fs.Bool("interactive", false, "Prompt for confirmation before each action")
+	parallelism := fs.Int("parallelism", 4, "Number of actions to apply concurrently")
+	rollbackLogPath := fs.String("rollback-log", defaultRollbackLog, "Path to append a rollback record to before each mutation")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		logrus.Fatal("usage: iam-scanner apply [-interactive] [-parallelism N] [-rollback-log path] <plan.json>")
+	}
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		logrus.Fatal(err)
+	}
+
+	rollbackFile, err := os.OpenFile(*rollbackLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	defer rollbackFile.Close()
+	var rollbackMu sync.Mutex
+
+	sessions := map[string]*session.Session{}
+	var sessionsMu sync.Mutex
+	sessionForAction := func(action Action) (*session.Session, error) {
+		if action.Profile == "" {
+			return nil, fmt.Errorf("action for %s/%s has no profile recorded (plan file from an older iam-scanner?)", action.Account, action.Username)
+		}
+		key := action.Profile + "|" + action.RoleARN
+		sessionsMu.Lock()
+		defer sessionsMu.Unlock()
+		if s, ok := sessions[key]; ok {
+			return s, nil
+		}
+		hub, err := session.NewSessionWithOptions(session.Options{Profile: action.Profile})
+		if err != nil {
+			return nil, err
+		}
+		s := hub
+		if action.RoleARN != "" {
+			s = assumeRoleSession(hub, action.RoleARN, action.ExternalID)
+		}
+		sessions[key] = s
+		return s, nil
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	sem := make(chan struct{}, *parallelism)
+	var wg sync.WaitGroup
+	for _, action := range p.Actions {
+		action := action
+		if *interactive && !confirmAction(stdin, action) {
+			logrus.Infof("Skipped %s for %s/%s", action.Type, action.Account, action.Username)
+			continue
+		}
+
+		sess, err := sessionForAction(action)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			applyAction(sess, action, rollbackFile, &rollbackMu)
+		}()
+	}
+	wg.Wait()
+}
+
+func confirmAction(stdin *bufio.Reader, action Action) bool {
+	fmt.Printf("Apply %s for %s/%s (%s)? [y/N] ", action.Type, action.Account, action.Username, action.Reason)
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(strings.ToLower(line)) == "y"
+}
+
+func applyAction(sess *session.Session, action Action, rollbackFile *os.File, mu *sync.Mutex) {
+	record := RollbackRecord{
+		Timestamp:   time.Now(),
+		Action:      action.Type,
+		Account:     action.Account,
+		Username:    action.Username,
+		AccessKeyID: action.AccessKeyID,
+	}
+
+	switch action.Type {
+	case ActionDeleteAccessKey:
+		record.AccessKeyCreateDate = accessKeyCreateDate(sess, action.Username, action.AccessKeyID)
+		appendRollbackRecord(rollbackFile, mu, record)
+		deleteAccessKeys(sess, action.AccessKeyID)
+	case ActionDeleteLoginProfile:
+		record.LoginProfileCreateDate = loginProfileCreateDate(sess, action.Username)
+		appendRollbackRecord(rollbackFile, mu, record)
+		deleteUserLoginProfile(sess, action.Username)
+	default:
+		logrus.Warnf("unknown action type %q for %s/%s, skipping", action.Type, action.Account, action.Username)
+	}
+}
+
+func accessKeyCreateDate(sess *session.Session, username string, accessKeyID string) *time.Time {
+	keys, err := listUserAccessKeys(sess, username)
+	if err != nil {
+		logrus.Error(err)
+		return nil
+	}
+	for _, key := range keys {
+		if *key.AccessKeyId == accessKeyID {
+			return key.CreateDate
+		}
+	}
+	return nil
+}
+
+func loginProfileCreateDate(sess *session.Session, username string) *time.Time {
+	svc := iam.New(sess)
+	result, err := svc.GetLoginProfile(&iam.GetLoginProfileInput{UserName: aws.String(username)})
+	if err != nil {
+		logrus.Error(err)
+		return nil
+	}
+	return result.LoginProfile.CreateDate
+}
+
+func appendRollbackRecord(f *os.File, mu *sync.Mutex, record RollbackRecord) {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := json.NewEncoder(f).Encode(record); err != nil {
+		logrus.Error(err)
+	}
+}
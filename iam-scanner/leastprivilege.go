@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/omerxx/hacks/report"
+	"github.com/sirupsen/logrus"
+)
+
+// suggestedPoliciesDir is where -suggest-policies writes one proposed policy
+// document per user.
+const suggestedPoliciesDir = "suggested-policies"
+
+var suggestPoliciesMode *bool
+var accessAdvisorDays float64
+
+// analyzeLeastPrivilege compares what a user's attached policies grant
+// against what Access Advisor shows they've actually used in the last
+// accessAdvisorDays days, at two levels:
+//
+//   - service level: a service granted but never authenticated against at
+//     all (e.g. attached ec2:* but never touched EC2) is always reported.
+//   - action level: for a service that IS used, a specific granted action
+//     Access Advisor has no record of (e.g. a policy listing s3:PutObject
+//     while Access Advisor's action-level tracking only ever saw
+//     s3:GetObject) is only reported once SimulatePrincipalPolicy confirms
+//     the principal would still be allowed to call it - so a denial from an
+//     SCP or permission boundary elsewhere in the account doesn't get
+//     suggested as "unused and safe to remove" when it was never actually
+//     exercisable. Service-wide wildcard grants such as s3:* are not
+//     expanded into individual actions, so they produce no action-level
+//     finding.
+//
+// In -suggest-policies mode it also writes a minimal proposed policy scoped
+// to the services actually used.
+func analyzeLeastPrivilege(session *session.Session, user *iam.User, account string, policies []*iam.AttachedPolicy) {
+	granted, actions, err := grantedActions(session, policies)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	used, usedActions, err := usedServices(session, *user.Arn)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	var unusedServices []string
+	for service := range granted {
+		if !used[service] {
+			unusedServices = append(unusedServices, service)
+		}
+	}
+	if len(unusedServices) > 0 {
+		findings.Emit(report.Finding{
+			Account:     account,
+			Principal:   *user.UserName,
+			RuleID:      "over-provisioned-services",
+			Severity:    report.SeverityLow,
+			Evidence:    fmt.Sprintf("granted access to %s but used none of it in the last %d days", strings.Join(unusedServices, ", "), int(accessAdvisorDays)),
+			Remediation: "scope the attached policies down to the services actually used, or remove them",
+		})
+	}
+
+	reportUnusedActions(session, user, account, actions, used, usedActions)
+
+	if *suggestPoliciesMode {
+		writeSuggestedPolicy(*user.UserName, used)
+	}
+}
+
+// reportUnusedActions narrows granted-but-Access-Advisor-unseen actions,
+// within services that ARE used, down to ones SimulatePrincipalPolicy
+// confirms the principal can still actually exercise, and emits one
+// action-level finding for whatever's left.
+func reportUnusedActions(session *session.Session, user *iam.User, account string, grantedActionNames []string, usedServiceSet, usedActionSet map[string]bool) {
+	var candidates []string
+	for _, action := range grantedActionNames {
+		service := serviceFromAction(action)
+		if service == "" || !usedServiceSet[service] {
+			// Either a bare "*" we can't attribute to a service, or a
+			// whole-service miss already covered by over-provisioned-services.
+			continue
+		}
+		if strings.HasSuffix(action, ":*") {
+			// A wildcard grant itself isn't a trackable action; individual
+			// granted actions (if any are listed elsewhere) are what get
+			// compared against usedActionSet.
+			continue
+		}
+		if usedActionSet[action] {
+			continue
+		}
+		candidates = append(candidates, action)
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	allowed, err := simulateAllowedActions(session, *user.Arn, candidates)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	if len(allowed) == 0 {
+		return
+	}
+	findings.Emit(report.Finding{
+		Account:     account,
+		Principal:   *user.UserName,
+		RuleID:      "over-provisioned-actions",
+		Severity:    report.SeverityLow,
+		Evidence:    fmt.Sprintf("granted %s but Access Advisor shows no use of it in the last %d days", strings.Join(allowed, ", "), int(accessAdvisorDays)),
+		Remediation: "scope the attached policies down to the actions actually used",
+	})
+}
+
+// simulateAllowedActions runs actions through SimulatePrincipalPolicy and
+// returns the subset that still evaluate to "allowed" for principalArn, in
+// up to 100-action batches per the API's limit.
+func simulateAllowedActions(session *session.Session, principalArn string, actions []string) ([]string, error) {
+	svc := iam.New(session)
+	var allowed []string
+	for i := 0; i < len(actions); i += 100 {
+		end := i + 100
+		if end > len(actions) {
+			end = len(actions)
+		}
+		batch := aws.StringSlice(actions[i:end])
+		err := svc.SimulatePrincipalPolicyPages(&iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(principalArn),
+			ActionNames:     batch,
+		}, func(page *iam.SimulatePolicyResponse, lastPage bool) bool {
+			for _, result := range page.EvaluationResults {
+				if result.EvalDecision != nil && *result.EvalDecision == iam.PolicyEvaluationDecisionTypeAllowed {
+					allowed = append(allowed, *result.EvalActionName)
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return allowed, nil
+}
+
+// policyDocument mirrors the subset of an IAM policy document we need to
+// read granted actions back out of.
+type policyDocument struct {
+	Version   string            `json:"Version,omitempty"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Effect   string          `json:"Effect"`
+	Action   json.RawMessage `json:"Action"`
+	Resource json.RawMessage `json:"Resource,omitempty"`
+}
+
+// actions normalizes Action, which IAM allows as either a single string or a
+// list of strings.
+func (s policyStatement) actions() []string {
+	var single string
+	if json.Unmarshal(s.Action, &single) == nil {
+		return []string{single}
+	}
+	var many []string
+	json.Unmarshal(s.Action, &many)
+	return many
+}
+
+// grantedActions returns both the set of AWS service prefixes ("s3", "ec2",
+// ...) and the full list of individual actions ("s3:GetObject", "s3:*", ...)
+// granted by the given attached policies' Allow statements.
+func grantedActions(session *session.Session, policies []*iam.AttachedPolicy) (services map[string]bool, actions []string, err error) {
+	svc := iam.New(session)
+	services = map[string]bool{}
+	for _, policy := range policies {
+		getPolicy, err := svc.GetPolicy(&iam.GetPolicyInput{PolicyArn: policy.PolicyArn})
+		if err != nil {
+			return nil, nil, err
+		}
+		version, err := svc.GetPolicyVersion(&iam.GetPolicyVersionInput{
+			PolicyArn: policy.PolicyArn,
+			VersionId: getPolicy.Policy.DefaultVersionId,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		decoded, err := url.QueryUnescape(*version.PolicyVersion.Document)
+		if err != nil {
+			return nil, nil, err
+		}
+		var doc policyDocument
+		if err := json.Unmarshal([]byte(decoded), &doc); err != nil {
+			return nil, nil, err
+		}
+		for _, statement := range doc.Statement {
+			if !strings.EqualFold(statement.Effect, "Allow") {
+				continue
+			}
+			for _, action := range statement.actions() {
+				actions = append(actions, action)
+				if service := serviceFromAction(action); service != "" {
+					services[service] = true
+				}
+			}
+		}
+	}
+	return services, actions, nil
+}
+
+// serviceFromAction extracts "s3" out of "s3:GetObject", or "" for a bare "*".
+func serviceFromAction(action string) string {
+	parts := strings.SplitN(action, ":", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[0]
+}
+
+// accessAdvisorPollBackoff and accessAdvisorPollBudget bound how long
+// usedServices waits on a GenerateServiceLastAccessedDetails job: Access
+// Advisor jobs routinely take longer than a few seconds, especially with
+// many of them in flight at once under -concurrency, so the poll backs off
+// exponentially instead of giving up after a handful of fixed-interval tries.
+const (
+	accessAdvisorPollBackoff    = 500 * time.Millisecond
+	accessAdvisorMaxPollBackoff = 5 * time.Second
+	accessAdvisorPollBudget     = 2 * time.Minute
+)
+
+// usedServices runs an action-level Access Advisor job for principalArn and
+// returns both the set of service namespaces it was authenticated against,
+// and the set of "service:Action" pairs (for services that support
+// action-level tracking) it actually exercised within the last
+// accessAdvisorDays days.
+func usedServices(session *session.Session, principalArn string) (services map[string]bool, actions map[string]bool, err error) {
+	svc := iam.New(session)
+	gen, err := svc.GenerateServiceLastAccessedDetails(&iam.GenerateServiceLastAccessedDetailsInput{
+		Arn:         aws.String(principalArn),
+		Granularity: aws.String(iam.AccessAdvisorUsageGranularityTypeActionLevel),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var details *iam.GetServiceLastAccessedDetailsOutput
+	backoff := accessAdvisorPollBackoff
+	deadline := time.Now().Add(accessAdvisorPollBudget)
+	for {
+		details, err = svc.GetServiceLastAccessedDetails(&iam.GetServiceLastAccessedDetailsInput{
+			JobId: gen.JobId,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if *details.JobStatus != iam.JobStatusTypeInProgress {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, nil, fmt.Errorf("access advisor job for %s still in progress after %s, giving up", principalArn, accessAdvisorPollBudget)
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > accessAdvisorMaxPollBackoff {
+			backoff = accessAdvisorMaxPollBackoff
+		}
+	}
+	if *details.JobStatus != iam.JobStatusTypeCompleted {
+		return nil, nil, fmt.Errorf("access advisor job for %s did not complete: %s", principalArn, *details.JobStatus)
+	}
+
+	cutoff := now.Add(-time.Duration(accessAdvisorDays) * 24 * time.Hour)
+	services = map[string]bool{}
+	actions = map[string]bool{}
+	for _, service := range details.ServicesLastAccessed {
+		if service.LastAuthenticated != nil && service.LastAuthenticated.After(cutoff) {
+			services[*service.ServiceNamespace] = true
+		}
+		for _, tracked := range service.TrackedActionsLastAccessed {
+			if tracked.LastAccessedTime != nil && tracked.LastAccessedTime.After(cutoff) {
+				actions[fmt.Sprintf("%s:%s", *service.ServiceNamespace, *tracked.ActionName)] = true
+			}
+		}
+	}
+	return services, actions, nil
+}
+
+// writeSuggestedPolicy writes a minimal policy document granting service-level
+// access (e.g. "s3:*") for every service in used. It's a starting point, not
+// a replacement for scoping down to individual actions.
+func writeSuggestedPolicy(username string, used map[string]bool) {
+	if len(used) == 0 {
+		return
+	}
+	actions := make([]string, 0, len(used))
+	for service := range used {
+		actions = append(actions, service+":*")
+	}
+	doc := policyDocument{
+		Version: "2012-10-17",
+		Statement: []policyStatement{{
+			Effect:   "Allow",
+			Action:   mustMarshal(actions),
+			Resource: mustMarshal([]string{"*"}),
+		}},
+	}
+
+	if err := os.MkdirAll(suggestedPoliciesDir, 0755); err != nil {
+		logrus.Error(err)
+		return
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	path := filepath.Join(suggestedPoliciesDir, username+".json")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		logrus.Error(err)
+		return
+	}
+	log.Infof("Wrote suggested policy for %s to %s", username, path)
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -5,10 +5,15 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/omerxx/hacks/notify"
+	"github.com/omerxx/hacks/report"
 	"github.com/sirupsen/logrus"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,24 +23,67 @@ import (
  * It will scan users access keys and passwords and report keys older than -age (defaults to 365 days)
  * If set to -active it will perform actions according to the rules listed below
  *
+ * -assume-role-arns lets a single "hub" profile chain into one or more "spoke" accounts
+ * via STS AssumeRole, so an org with dozens of accounts can be scanned from one set of
+ * credentials instead of maintaining a local profile per account.
+ *
+ * -active no longer mutates IAM directly: it records every intended change into a plan
+ * file (-plan-file, default "iam-plan.json"). Run `iam-scanner apply <plan.json>` as a
+ * second step to actually execute it; apply writes a rollback record for every action
+ * before performing it, so a run can be reconstructed afterwards.
+ *
+ * Users within an account are checked concurrently, bounded by -concurrency. Sessions
+ * are built with -retry-max so throttling from IAM on large orgs is absorbed by the
+ * SDK's own exponential-backoff retryer instead of failing the scan.
+ *
+ * -notify-config points at a YAML file declaring notification sinks (Slack, PagerDuty,
+ * webhook, SNS); every finding emitted during the scan is also routed through it.
+ *
  * Rules:
  * [*] If a user on an account never used login - disable his console access
  * [*] If a key has never been used - remove it
  * [*] If a key hasn’t been used in over a year - remove it
  * [not implemented] If a user has never accessed the console and hasn’t got keys (or has unused keys), delete the user
  */
+// accountAuth carries both the label a finding is reported under and
+// whatever a later `apply` run needs to rebuild the same session: the CLI
+// profile used for the hub session, plus a role ARN/external ID if the
+// account was reached via -assume-role-arns rather than scanned directly.
+type accountAuth struct {
+	Label      string
+	Profile    string
+	RoleARN    string
+	ExternalID string
+}
+
 var allowedCredentialsAge float64
 var activeMode *bool
 var focusMode *bool
-var prefix string
 var now = time.Now()
 var log = logrus.New()
+var findings *report.Emitter
+var concurrency int
+var retryMax int
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		runApplyCommand(os.Args[2:])
+		return
+	}
+
 	var profilesFlag = flag.String("profiles", "default", "An AWS CLI profile name, or comma-separated list for multiple")
 	var ageFlag = flag.Float64("age", 365, "Age in DAYS beyond keys and activity will be addressed")
-	activeMode = flag.Bool("active", false, "Active mode - deactivates users and keys according to rules")
+	var assumeRoleARNsFlag = flag.String("assume-role-arns", "", "Comma-separated list of IAM role ARNs to assume from the -profiles hub session, one per spoke account (e.g. arn:aws:iam::111111111111:role/SecurityAudit)")
+	var externalIDFlag = flag.String("external-id", "", "External ID to pass on every -assume-role-arns AssumeRole call, if the spoke roles require one")
+	var outputFlag = flag.String("output", "text", "Output format for findings: text, json, jsonl, or sarif")
+	var notifyConfigFlag = flag.String("notify-config", "", "Path to a YAML config declaring notification sinks (Slack, PagerDuty, webhook, SNS) for findings")
+	var planFileFlag = flag.String("plan-file", defaultPlanFile, "Where -active writes the plan of intended changes, for a later `apply` run")
+	var accessAdvisorDaysFlag = flag.Float64("access-advisor-days", 180, "Access Advisor lookback window in DAYS used to decide whether a granted service has actually been used")
+	var concurrencyFlag = flag.Int("concurrency", 10, "Number of users to check concurrently per account")
+	var retryMaxFlag = flag.Int("retry-max", 10, "Max SDK retries per IAM call, to survive Throttling on large orgs")
+	activeMode = flag.Bool("active", false, "Active mode - records intended changes into -plan-file instead of applying them; run `iam-scanner apply <plan-file>` to execute")
 	focusMode = flag.Bool("focus", false, "Focus mode - only shows actionable items")
+	suggestPoliciesMode = flag.Bool("suggest-policies", false, "Write a minimal proposed IAM policy per user, scoped to services actually used per Access Advisor")
 	flag.Parse()
 
 	logFormat := new(logrus.TextFormatter)
@@ -43,84 +91,204 @@ func main() {
 	logrus.SetFormatter(logFormat)
 	logFormat.FullTimestamp = true
 
+	outputFormat, err := report.ParseFormat(*outputFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	findings = report.NewEmitter(outputFormat, os.Stdout)
+	defer findings.Close()
+
+	if *notifyConfigFlag != "" {
+		cfg, err := notify.LoadConfig(*notifyConfigFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		dispatcher, err := notify.NewDispatcher(cfg, *notifyConfigFlag+".seen.json")
+		if err != nil {
+			log.Fatal(err)
+		}
+		findings.Subscribe(dispatcher.Dispatch)
+		defer func() {
+			if err := dispatcher.Save(); err != nil {
+				log.Error(err)
+			}
+		}()
+	}
+
+	if *activeMode {
+		currentPlan = &Plan{CreatedAt: now}
+		defer writePlan(currentPlan, *planFileFlag)
+	}
+
 	var profiles = strings.Split(*profilesFlag, ",")
+	var assumeRoleARNs []string
+	if *assumeRoleARNsFlag != "" {
+		assumeRoleARNs = strings.Split(*assumeRoleARNsFlag, ",")
+	}
 	allowedCredentialsAge = *ageFlag
+	accessAdvisorDays = *accessAdvisorDaysFlag
+	concurrency = *concurrencyFlag
+	retryMax = *retryMaxFlag
 	for _, profile := range profiles {
-		log.Infof("\n-----------------\nScanning account %s\n-----------------", profile)
-		checkAccountCredentials(profile)
+		if len(assumeRoleARNs) == 0 {
+			log.Infof("\n-----------------\nScanning account %s\n-----------------", profile)
+			checkAccountCredentials(profile)
+			continue
+		}
+		hub, err := session.NewSessionWithOptions(session.Options{
+			Profile: profile,
+			Config:  aws.Config{MaxRetries: aws.Int(retryMax)},
+		})
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		for _, roleARN := range assumeRoleARNs {
+			roleARN = strings.TrimSpace(roleARN)
+			log.Infof("\n-----------------\nScanning account %s (hub profile %s)\n-----------------", roleARN, profile)
+			spoke := assumeRoleSession(hub, roleARN, *externalIDFlag)
+			checkAccountCredentialsForSession(spoke, accountAuth{
+				Label:      roleARN,
+				Profile:    profile,
+				RoleARN:    roleARN,
+				ExternalID: *externalIDFlag,
+			})
+		}
 	}
 }
 
+// assumeRoleSession returns a session backed by stscreds.AssumeRoleProvider, which
+// transparently refreshes the assumed-role credentials as they near expiry, so a
+// single long-running scan can keep iterating spoke accounts without re-assuming.
+func assumeRoleSession(hub *session.Session, roleARN string, externalID string) *session.Session {
+	creds := stscreds.NewCredentials(hub, roleARN, func(p *stscreds.AssumeRoleProvider) {
+		if externalID != "" {
+			p.ExternalID = aws.String(externalID)
+		}
+	})
+	return hub.Copy(&aws.Config{Credentials: creds})
+}
+
 func checkAccountCredentials(profile string) {
-	session, _ := session.NewSessionWithOptions(session.Options{
+	session, err := session.NewSessionWithOptions(session.Options{
 		Profile: profile,
+		Config:  aws.Config{MaxRetries: aws.Int(retryMax)},
 	})
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	checkAccountCredentialsForSession(session, accountAuth{Label: profile, Profile: profile})
+}
+
+func checkAccountCredentialsForSession(session *session.Session, auth accountAuth) {
 	users, err := listUsers(session)
 	if err != nil {
 		logrus.Error(err)
 	}
-	checkUsersCredentialsAge(session, users, profile)
+	checkUsersCredentialsAge(session, users, auth)
 }
 
 func listUsers(session *session.Session) ([]*iam.User, error) {
 	svc := iam.New(session)
-	input := &iam.ListUsersInput{}
-	result, err := svc.ListUsers(input)
+	var users []*iam.User
+	err := svc.ListUsersPages(&iam.ListUsersInput{}, func(page *iam.ListUsersOutput, lastPage bool) bool {
+		users = append(users, page.Users...)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-	return result.Users, nil
+	return users, nil
 }
 
-func checkUsersCredentialsAge(session *session.Session, users []*iam.User, profile string) {
+// checkUsersCredentialsAge checks every user in the account, bounded by
+// -concurrency worker goroutines so an account with thousands of principals
+// doesn't run them one at a time.
+func checkUsersCredentialsAge(session *session.Session, users []*iam.User, auth accountAuth) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
 	for _, user := range users {
-		prefix = fmt.Sprintf("%s | %s: ", profile, *user.UserName)
-		listUserRoles(session, user)
-		checkUsersConsoleLoginAge(session, user)
-		checkUsersAccessKeysAge(session, user)
-		logrus.Infof("\n")
+		user := user
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			listUserRoles(session, user, auth)
+			checkUsersConsoleLoginAge(session, user, auth)
+			checkUsersAccessKeysAge(session, user, auth)
+			logrus.Infof("\n")
+		}()
 	}
+	wg.Wait()
 }
 
-func listUserRoles(session *session.Session, user *iam.User) {
+func listUserRoles(session *session.Session, user *iam.User, auth accountAuth) {
 	// list attached user policies
 	svc := iam.New(session)
-	input := &iam.ListAttachedUserPoliciesInput{
+	var attached []*iam.AttachedPolicy
+	err := svc.ListAttachedUserPoliciesPages(&iam.ListAttachedUserPoliciesInput{
 		UserName: aws.String(*user.UserName),
-	}
-	policies, err := svc.ListAttachedUserPolicies(input)
+	}, func(page *iam.ListAttachedUserPoliciesOutput, lastPage bool) bool {
+		attached = append(attached, page.AttachedPolicies...)
+		return true
+	})
 	if err != nil {
 		logrus.Error(err)
 	}
 	logrus.Infof("---------%s---------", *user.UserName)
-	for _, policy := range policies.AttachedPolicies {
+	for _, policy := range attached {
 		if strings.Contains(*policy.PolicyName, "FullAccess") || strings.Contains(*policy.PolicyName, "Admin") || strings.Contains(*policy.PolicyName, "admin") {
-			log.WithFields(logrus.Fields{"Issue": "Full Access permissions"}).Errorf("\t%s", *policy.PolicyName)
+			findings.Emit(report.Finding{
+				Account:     auth.Label,
+				Principal:   *user.UserName,
+				Resource:    *policy.PolicyArn,
+				RuleID:      "admin-policy",
+				Severity:    report.SeverityHigh,
+				Evidence:    fmt.Sprintf("user has full access policy %s attached", *policy.PolicyName),
+				Remediation: "scope the attached policy down to the permissions the user actually needs",
+			})
 		} else if *focusMode == false {
 			log.Infof("\t%s", *policy.PolicyName)
 		}
 	}
+
+	analyzeLeastPrivilege(session, user, auth.Label, attached)
 }
 
-func checkUsersConsoleLoginAge(session *session.Session, user *iam.User) {
+func checkUsersConsoleLoginAge(session *session.Session, user *iam.User, auth accountAuth) {
 	if hasLoginProfile(session, user) == true {
 		if user.PasswordLastUsed == nil {
-			// log.Warn(fmt.Sprintf("%s Password never used, but user has a login profile", prefix))
-			log.WithFields(
-				logrus.Fields{"Issue": "password never used, but user has a login profile"},
-			).Warn()
+			findings.Emit(report.Finding{
+				Account:     auth.Label,
+				Principal:   *user.UserName,
+				RuleID:      "unused-login-profile",
+				Severity:    report.SeverityMedium,
+				Evidence:    "password never used, but user has a login profile",
+				Remediation: "disable console access for this user",
+			})
 			if *activeMode {
-				log.Warn(fmt.Sprintf("%s Disabling console access", prefix))
-				deleteUserLoginProfile(session, *user.UserName)
+				log.Warnf("%s | %s: Planning to disable console access", auth.Label, *user.UserName)
+				currentPlan.add(Action{
+					Type:       ActionDeleteLoginProfile,
+					Account:    auth.Label,
+					Profile:    auth.Profile,
+					RoleARN:    auth.RoleARN,
+					ExternalID: auth.ExternalID,
+					Username:   *user.UserName,
+					Reason:     "password never used, but user has a login profile",
+				})
 			}
 		} else if olderThanAge(*user.PasswordLastUsed) {
-			log.WithFields(
-				logrus.Fields{
-					"Optional": "Remove console profile",
-				}).Info(fmt.Sprintf(
-				// "%s Password last used %d days ago", prefix, int(now.Sub(*user.PasswordLastUsed).Hours()/24),
-				"\tPassword last used %d days ago", int(now.Sub(*user.PasswordLastUsed).Hours()/24),
-			))
+			findings.Emit(report.Finding{
+				Account:     auth.Label,
+				Principal:   *user.UserName,
+				RuleID:      "stale-login-profile",
+				Severity:    report.SeverityLow,
+				Evidence:    fmt.Sprintf("password last used %d days ago", int(now.Sub(*user.PasswordLastUsed).Hours()/24)),
+				Remediation: "remove the console profile if it's no longer needed",
+			})
 		}
 	}
 }
@@ -159,7 +327,7 @@ func deleteUserLoginProfile(session *session.Session, username string) {
 	}
 }
 
-func checkUsersAccessKeysAge(session *session.Session, user *iam.User) {
+func checkUsersAccessKeysAge(session *session.Session, user *iam.User, auth accountAuth) {
 	userAccessKeys, err := listUserAccessKeys(session, *user.UserName)
 	if err != nil {
 		fmt.Println(err)
@@ -170,21 +338,50 @@ func checkUsersAccessKeysAge(session *session.Session, user *iam.User) {
 			fmt.Println(err)
 		}
 		if lastUsed == nil {
-			log.Warnf("\tAccess key never used [%s]", *key.AccessKeyId)
+			findings.Emit(report.Finding{
+				Account:     auth.Label,
+				Principal:   *user.UserName,
+				Resource:    *key.AccessKeyId,
+				RuleID:      "unused-access-key",
+				Severity:    report.SeverityMedium,
+				Evidence:    "access key has never been used",
+				Remediation: "remove the access key",
+			})
 			if *activeMode {
-				log.Warn("Removing access key")
-				deleteAccessKeys(session, *key.AccessKeyId)
+				log.Warnf("%s | %s: Planning to remove access key", auth.Label, *user.UserName)
+				currentPlan.add(Action{
+					Type:        ActionDeleteAccessKey,
+					Account:     auth.Label,
+					Profile:     auth.Profile,
+					RoleARN:     auth.RoleARN,
+					ExternalID:  auth.ExternalID,
+					Username:    *user.UserName,
+					AccessKeyID: *key.AccessKeyId,
+					Reason:      "access key has never been used",
+				})
 			}
 		} else if olderThanAge(*lastUsed) {
-			log.WithFields(
-				logrus.Fields{
-					"Optional": "Rotate key",
-				}).Info(fmt.Sprintf(
-				"\tKey %s last used %d days ago", *key.AccessKeyId, int(now.Sub(*lastUsed).Hours()/24),
-			))
+			findings.Emit(report.Finding{
+				Account:     auth.Label,
+				Principal:   *user.UserName,
+				Resource:    *key.AccessKeyId,
+				RuleID:      "stale-access-key",
+				Severity:    report.SeverityLow,
+				Evidence:    fmt.Sprintf("key last used %d days ago", int(now.Sub(*lastUsed).Hours()/24)),
+				Remediation: "rotate or remove the access key",
+			})
 			if *activeMode {
-				log.Warn(fmt.Sprintf("%s Removing access key", prefix))
-				deleteAccessKeys(session, *key.AccessKeyId)
+				log.Warnf("%s | %s: Planning to remove access key", auth.Label, *user.UserName)
+				currentPlan.add(Action{
+					Type:        ActionDeleteAccessKey,
+					Account:     auth.Label,
+					Profile:     auth.Profile,
+					RoleARN:     auth.RoleARN,
+					ExternalID:  auth.ExternalID,
+					Username:    *user.UserName,
+					AccessKeyID: *key.AccessKeyId,
+					Reason:      fmt.Sprintf("key last used %d days ago", int(now.Sub(*lastUsed).Hours()/24)),
+				})
 			}
 		}
 	}
@@ -207,14 +404,17 @@ func olderThanAge(input time.Time) bool {
 
 func listUserAccessKeys(session *session.Session, username string) ([]*iam.AccessKeyMetadata, error) {
 	svc := iam.New(session)
-	input := &iam.ListAccessKeysInput{
+	var keys []*iam.AccessKeyMetadata
+	err := svc.ListAccessKeysPages(&iam.ListAccessKeysInput{
 		UserName: aws.String(username),
-	}
-	result, err := svc.ListAccessKeys(input)
+	}, func(page *iam.ListAccessKeysOutput, lastPage bool) bool {
+		keys = append(keys, page.AccessKeyMetadata...)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-	return result.AccessKeyMetadata, nil
+	return keys, nil
 }
 
 func getAccessKeyLastUsed(session *session.Session, accessKeyID string) (*time.Time, error) {
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// defaultPlanFile is where -active writes the actions it would otherwise
+// have applied immediately.
+const defaultPlanFile = "iam-plan.json"
+
+// ActionType identifies the kind of mutation an Action performs. It doubles
+// as the rollback record's discriminator, so apply knows what it undid.
+type ActionType string
+
+const (
+	ActionDeleteLoginProfile ActionType = "delete-login-profile"
+	ActionDeleteAccessKey    ActionType = "delete-access-key"
+)
+
+// Action is one intended mutation recorded by -active, to be executed later
+// by `iam-scanner apply`. Account is a human-readable label (the CLI profile,
+// or the spoke role ARN for an -assume-role-arns scan); Profile, RoleARN, and
+// ExternalID are what apply actually needs to rebuild the session, since the
+// plan file is its only input and it doesn't have the original scan's flags.
+type Action struct {
+	Type        ActionType `json:"type"`
+	Account     string     `json:"account"`
+	Profile     string     `json:"profile"`
+	RoleARN     string     `json:"role_arn,omitempty"`
+	ExternalID  string     `json:"external_id,omitempty"`
+	Username    string     `json:"username"`
+	AccessKeyID string     `json:"access_key_id,omitempty"`
+	Reason      string     `json:"reason"`
+}
+
+// Plan is the full set of actions a scan would take in active mode.
+type Plan struct {
+	CreatedAt time.Time `json:"created_at"`
+	Actions   []Action  `json:"actions"`
+
+	mu sync.Mutex
+}
+
+// currentPlan accumulates actions for the running scan when -active is set;
+// nil otherwise.
+var currentPlan *Plan
+
+func (p *Plan) add(a Action) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Actions = append(p.Actions, a)
+}
+
+// writePlan renders p as JSON to path. Called via defer, so it runs even if
+// the scan encountered errors partway through - a partial plan is still
+// useful.
+func writePlan(p *Plan, path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Infof("Wrote plan with %d action(s) to %s. Run `iam-scanner apply %s` to execute it.", len(p.Actions), path, path)
+}
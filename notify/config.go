@@ -0,0 +1,54 @@
+// Package notify dispatches findings emitted by hacks' scanners to external
+// sinks (Slack, PagerDuty, a generic webhook, or SNS), as declared in a YAML
+// config: which sinks exist, the severity each one requires, and which rules
+// route to it. Hook a Dispatcher up to a running scan with
+// emitter.Subscribe(dispatcher.Dispatch), and defer dispatcher.Save() so its
+// de-dup cache survives to the next scheduled run.
+package notify
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the top-level notify.yaml shape.
+type Config struct {
+	Sinks []SinkConfig `yaml:"sinks"`
+}
+
+// SinkConfig declares one notification sink and its routing.
+type SinkConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // slack, pagerduty, webhook, sns
+	URL  string `yaml:"url,omitempty"`
+	// RoutingKey is the PagerDuty Events API v2 integration routing key.
+	RoutingKey string `yaml:"routing_key,omitempty"`
+	// SNSTopicARN and Profile are used by the sns sink type.
+	SNSTopicARN string `yaml:"sns_topic_arn,omitempty"`
+	Profile     string `yaml:"profile,omitempty"`
+
+	// SeverityThreshold is the minimum report.Severity this sink receives.
+	// Defaults to report.SeverityInfo (everything) if empty.
+	SeverityThreshold string `yaml:"severity_threshold,omitempty"`
+	// Rules restricts this sink to specific rule_id values. Empty means
+	// every rule that clears SeverityThreshold is routed here.
+	Rules []string `yaml:"rules,omitempty"`
+	// RateLimitPerMinute caps how many findings this sink accepts per
+	// rolling minute. Zero means unlimited.
+	RateLimitPerMinute int `yaml:"rate_limit_per_minute,omitempty"`
+}
+
+// LoadConfig reads and parses a notify config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing notify config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
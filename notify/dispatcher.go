@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/omerxx/hacks/report"
+	"github.com/sirupsen/logrus"
+)
+
+// dedupeWindow is how long a repeated (account, principal, rule_id) finding
+// is suppressed, so a scan that's re-run on a schedule doesn't re-notify on
+// something it already reported last time.
+const dedupeWindow = 24 * time.Hour
+
+var severityRank = map[string]int{
+	report.SeverityInfo:     0,
+	report.SeverityLow:      1,
+	report.SeverityMedium:   2,
+	report.SeverityHigh:     3,
+	report.SeverityCritical: 4,
+}
+
+type route struct {
+	sink    Sink
+	minRank int
+	rules   []string // rule_id allowlist; empty means every rule
+	limiter *rateLimiter
+}
+
+// Dispatcher routes findings to the sinks declared in a Config, applying
+// each sink's severity threshold, rule routing, and rate limit, plus a
+// de-dup cache shared across all sinks.
+type Dispatcher struct {
+	routes []route
+
+	seenPath string
+	mu       sync.Mutex
+	seen     map[string]time.Time
+}
+
+// NewDispatcher builds every sink declared in cfg and wires up its routing.
+// seenPath is where the de-dup cache persists between runs; pass "" to keep
+// it in-memory only. Both hacks tools are one-shot CLIs meant to be re-run
+// on a schedule (cron), so without persisting this to disk the dedupe
+// window would never actually suppress a repeat finding across runs - call
+// Save before the process exits to write it back out.
+func NewDispatcher(cfg *Config, seenPath string) (*Dispatcher, error) {
+	d := &Dispatcher{seen: map[string]time.Time{}, seenPath: seenPath}
+	if err := d.loadSeen(); err != nil {
+		logrus.Warnf("notify: couldn't load de-dup cache %s, starting empty: %v", seenPath, err)
+	}
+	for _, sc := range cfg.Sinks {
+		sink, err := buildSink(sc)
+		if err != nil {
+			return nil, err
+		}
+		rank, ok := severityRank[sc.SeverityThreshold]
+		if !ok {
+			rank = severityRank[report.SeverityInfo]
+		}
+		d.routes = append(d.routes, route{
+			sink:    sink,
+			minRank: rank,
+			rules:   sc.Rules,
+			limiter: newRateLimiter(sc.RateLimitPerMinute),
+		})
+	}
+	return d, nil
+}
+
+// Dispatch is a report.Listener: subscribe it with
+// emitter.Subscribe(dispatcher.Dispatch) to notify on every finding a scan
+// emits.
+func (d *Dispatcher) Dispatch(f report.Finding) {
+	if d.alreadySeen(f) {
+		return
+	}
+	for _, r := range d.routes {
+		if severityRank[f.Severity] < r.minRank {
+			continue
+		}
+		if !matchesRules(f.RuleID, r.rules) {
+			continue
+		}
+		if !r.limiter.Allow() {
+			logrus.Warnf("notify: rate limit hit for sink %s, dropping finding %s", r.sink.Name(), f.RuleID)
+			continue
+		}
+		if err := r.sink.Send(f); err != nil {
+			logrus.Errorf("notify: sink %s: %v", r.sink.Name(), err)
+		}
+	}
+}
+
+func matchesRules(ruleID string, rules []string) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	for _, r := range rules {
+		if r == ruleID {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) alreadySeen(f report.Finding) bool {
+	key := fmt.Sprintf("%s|%s|%s", f.Account, f.Principal, f.RuleID)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.seen[key]; ok && time.Since(last) < dedupeWindow {
+		return true
+	}
+	d.seen[key] = time.Now()
+	return false
+}
+
+func (d *Dispatcher) loadSeen() error {
+	if d.seenPath == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(d.seenPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &d.seen)
+}
+
+// Save persists the de-dup cache to seenPath, dropping entries older than
+// dedupeWindow so the file doesn't grow unbounded across scheduled runs.
+// Callers should defer it right after building the Dispatcher.
+func (d *Dispatcher) Save() error {
+	if d.seenPath == "" {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cutoff := time.Now().Add(-dedupeWindow)
+	pruned := make(map[string]time.Time, len(d.seen))
+	for key, last := range d.seen {
+		if last.After(cutoff) {
+			pruned[key] = last
+		}
+	}
+	d.seen = pruned
+
+	data, err := json.MarshalIndent(d.seen, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(d.seenPath, data, 0644)
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omerxx/hacks/report"
+)
+
+// recordingSink collects every finding it's sent, for assertions.
+type recordingSink struct {
+	received []report.Finding
+}
+
+func (r *recordingSink) Name() string { return "recording" }
+
+func (r *recordingSink) Send(f report.Finding) error {
+	r.received = append(r.received, f)
+	return nil
+}
+
+func newTestDispatcher(sink Sink, limiter *rateLimiter) *Dispatcher {
+	return &Dispatcher{
+		seen: map[string]time.Time{},
+		routes: []route{{
+			sink:    sink,
+			minRank: severityRank[report.SeverityInfo],
+			limiter: limiter,
+		}},
+	}
+}
+
+func TestDispatcherDedupesRepeatedFindings(t *testing.T) {
+	sink := &recordingSink{}
+	d := newTestDispatcher(sink, newRateLimiter(0))
+
+	f := report.Finding{Account: "111111111111", Principal: "alice", RuleID: "stale-access-key", Severity: report.SeverityLow}
+	d.Dispatch(f)
+	d.Dispatch(f)
+
+	if len(sink.received) != 1 {
+		t.Fatalf("want 1 delivery after a repeated finding, got %d", len(sink.received))
+	}
+}
+
+func TestDispatcherAllowsDistinctFindings(t *testing.T) {
+	sink := &recordingSink{}
+	d := newTestDispatcher(sink, newRateLimiter(0))
+
+	d.Dispatch(report.Finding{Account: "111111111111", Principal: "alice", RuleID: "stale-access-key", Severity: report.SeverityLow})
+	d.Dispatch(report.Finding{Account: "111111111111", Principal: "bob", RuleID: "stale-access-key", Severity: report.SeverityLow})
+
+	if len(sink.received) != 2 {
+		t.Fatalf("want 2 deliveries for distinct principals, got %d", len(sink.received))
+	}
+}
+
+func TestDispatcherRateLimitsPerSink(t *testing.T) {
+	sink := &recordingSink{}
+	d := newTestDispatcher(sink, newRateLimiter(1))
+
+	for _, ruleID := range []string{"rule-a", "rule-b", "rule-c"} {
+		d.Dispatch(report.Finding{Account: "111111111111", Principal: "alice", RuleID: ruleID, Severity: report.SeverityLow})
+	}
+
+	if len(sink.received) != 1 {
+		t.Fatalf("want 1 delivery under a rate limit of 1/minute, got %d", len(sink.received))
+	}
+}
+
+func TestDispatcherDropsBelowSeverityThreshold(t *testing.T) {
+	sink := &recordingSink{}
+	d := &Dispatcher{
+		seen: map[string]time.Time{},
+		routes: []route{{
+			sink:    sink,
+			minRank: severityRank[report.SeverityHigh],
+			limiter: newRateLimiter(0),
+		}},
+	}
+
+	d.Dispatch(report.Finding{Account: "111111111111", Principal: "alice", RuleID: "stale-access-key", Severity: report.SeverityLow})
+	d.Dispatch(report.Finding{Account: "111111111111", Principal: "alice", RuleID: "admin-policy", Severity: report.SeverityHigh})
+
+	if len(sink.received) != 1 || sink.received[0].RuleID != "admin-policy" {
+		t.Fatalf("want only the high-severity finding delivered, got %+v", sink.received)
+	}
+}
+
+func TestDispatcherDedupeCachePersistsAcrossInstances(t *testing.T) {
+	seenPath := filepath.Join(t.TempDir(), "seen.json")
+	cfg := &Config{}
+	f := report.Finding{Account: "111111111111", Principal: "alice", RuleID: "stale-access-key", Severity: report.SeverityLow}
+
+	first, err := NewDispatcher(cfg, seenPath)
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+	first.Dispatch(f)
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	second, err := NewDispatcher(cfg, seenPath)
+	if err != nil {
+		t.Fatalf("NewDispatcher on a re-run: %v", err)
+	}
+	if !second.alreadySeen(f) {
+		t.Fatalf("want the finding seen by a prior run (simulating the next scheduled run) to stay deduped after reloading %s", seenPath)
+	}
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/omerxx/hacks/report"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutySink triggers a PagerDuty Events API v2 event, deduplicated on
+// the PagerDuty side by (account, principal, rule_id).
+type PagerDutySink struct {
+	name       string
+	routingKey string
+}
+
+func NewPagerDutySink(name, routingKey string) *PagerDutySink {
+	return &PagerDutySink{name: name, routingKey: routingKey}
+}
+
+func (p *PagerDutySink) Name() string { return p.name }
+
+func (p *PagerDutySink) Send(f report.Finding) error {
+	event := map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    fmt.Sprintf("%s:%s:%s", f.Account, f.Principal, f.RuleID),
+		"payload": map[string]string{
+			"summary":  fmt.Sprintf("%s: %s", f.RuleID, f.Evidence),
+			"source":   f.Account,
+			"severity": pagerDutySeverity(f.Severity),
+		},
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(pagerDutyEventsURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pagerduty events api returned %s", resp.Status)
+	}
+	return nil
+}
+
+// pagerDutySeverity maps a report.Severity onto the fixed set PagerDuty's
+// Events API accepts: critical, error, warning, info.
+func pagerDutySeverity(severity string) string {
+	switch severity {
+	case report.SeverityCritical:
+		return "critical"
+	case report.SeverityHigh:
+		return "error"
+	case report.SeverityMedium:
+		return "warning"
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter allows at most max sends per rolling minute. A non-positive
+// max disables limiting.
+type rateLimiter struct {
+	max int
+
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{max: perMinute}
+}
+
+// Allow reports whether another send is permitted right now, and records it
+// if so.
+func (r *rateLimiter) Allow() bool {
+	if r.max <= 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := time.Now().Add(-time.Minute)
+	kept := r.sent[:0]
+	for _, t := range r.sent {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	r.sent = kept
+
+	if len(r.sent) >= r.max {
+		return false
+	}
+	r.sent = append(r.sent, time.Now())
+	return true
+}
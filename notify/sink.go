@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/omerxx/hacks/report"
+)
+
+// Sink delivers one finding to an external system.
+type Sink interface {
+	Name() string
+	Send(f report.Finding) error
+}
+
+func buildSink(sc SinkConfig) (Sink, error) {
+	switch sc.Type {
+	case "slack":
+		return NewSlackSink(sc.Name, sc.URL), nil
+	case "pagerduty":
+		return NewPagerDutySink(sc.Name, sc.RoutingKey), nil
+	case "webhook":
+		return NewWebhookSink(sc.Name, sc.URL), nil
+	case "sns":
+		return NewSNSSink(sc.Name, sc.SNSTopicARN, sc.Profile)
+	default:
+		return nil, fmt.Errorf("notify: sink %q: unknown type %q (want slack, pagerduty, webhook, or sns)", sc.Name, sc.Type)
+	}
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/omerxx/hacks/report"
+)
+
+// SlackSink posts a finding to a Slack incoming webhook.
+type SlackSink struct {
+	name string
+	url  string
+}
+
+func NewSlackSink(name, url string) *SlackSink {
+	return &SlackSink{name: name, url: url}
+}
+
+func (s *SlackSink) Name() string { return s.name }
+
+func (s *SlackSink) Send(f report.Finding) error {
+	text := fmt.Sprintf("*[%s]* %s %s/%s: %s", f.Severity, f.RuleID, f.Account, f.Principal, f.Evidence)
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
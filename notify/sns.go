@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/omerxx/hacks/report"
+)
+
+// SNSSink publishes a finding, JSON-encoded, as an SNS message.
+type SNSSink struct {
+	name     string
+	topicARN string
+	svc      *sns.SNS
+}
+
+// NewSNSSink builds a session from profile (the empty string uses the
+// default AWS CLI profile resolution) and an SNS client against it.
+func NewSNSSink(name, topicARN, profile string) (*SNSSink, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SNSSink{name: name, topicARN: topicARN, svc: sns.New(sess)}, nil
+}
+
+func (s *SNSSink) Name() string { return s.name }
+
+func (s *SNSSink) Send(f report.Finding) error {
+	body, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	_, err = s.svc.Publish(&sns.PublishInput{
+		TopicArn: aws.String(s.topicARN),
+		Message:  aws.String(string(body)),
+	})
+	return err
+}
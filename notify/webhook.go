@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/omerxx/hacks/report"
+)
+
+// WebhookSink POSTs the finding, JSON-encoded, to an arbitrary URL.
+type WebhookSink struct {
+	name string
+	url  string
+}
+
+func NewWebhookSink(name, url string) *WebhookSink {
+	return &WebhookSink{name: name, url: url}
+}
+
+func (w *WebhookSink) Name() string { return w.name }
+
+func (w *WebhookSink) Send(f report.Finding) error {
+	body, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", w.url, resp.Status)
+	}
+	return nil
+}
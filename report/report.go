@@ -0,0 +1,152 @@
+// Package report defines the stable Finding schema shared by hacks' scanners
+// and the Emitter that renders a stream of findings as human text, JSON,
+// JSONL, or SARIF, so results can be diffed across runs, piped into a SIEM,
+// or fed into ticketing instead of scraped from logrus output.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Finding is the stable unit every detection in hacks is reported as,
+// regardless of which tool or rule produced it.
+type Finding struct {
+	Account     string    `json:"account"`
+	Principal   string    `json:"principal,omitempty"`
+	Resource    string    `json:"resource,omitempty"`
+	RuleID      string    `json:"rule_id"`
+	Severity    string    `json:"severity"`
+	Evidence    string    `json:"evidence,omitempty"`
+	Remediation string    `json:"remediation,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+}
+
+// Severity levels used across rule_id values. Kept as plain strings in
+// Finding so the schema stays stable even if new severities are added.
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Format selects how an Emitter renders findings.
+type Format string
+
+const (
+	FormatText  Format = "text"
+	FormatJSON  Format = "json"
+	FormatJSONL Format = "jsonl"
+	FormatSARIF Format = "sarif"
+)
+
+// ParseFormat validates a -output flag value.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatText, FormatJSON, FormatJSONL, FormatSARIF:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (want text, json, jsonl, or sarif)", s)
+	}
+}
+
+// Listener is notified of every finding as it's emitted, regardless of
+// output Format. It's how the notify package hooks a Dispatcher up to a
+// scan without every call site needing to know notifications exist.
+type Listener func(Finding)
+
+// Emitter collects findings as they're detected and renders them in the
+// configured Format. JSONL findings are written as they arrive; text findings
+// go straight to logrus; json and sarif are buffered and rendered on Close,
+// since both need the full result set to produce one document. Emitter is
+// safe for concurrent use; both hacks tools dispatch detections from worker
+// goroutines.
+type Emitter struct {
+	format    Format
+	out       io.Writer
+	mu        sync.Mutex
+	findings  []Finding
+	listeners []Listener
+}
+
+// NewEmitter builds an Emitter that writes to out in the given format.
+func NewEmitter(format Format, out io.Writer) *Emitter {
+	return &Emitter{format: format, out: out}
+}
+
+// Subscribe registers l to be called with every finding Emit sees from now
+// on, in addition to however the Emitter itself renders it.
+func (e *Emitter) Subscribe(l Listener) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, l)
+}
+
+// Emit records a single finding. FirstSeen defaults to now if unset.
+func (e *Emitter) Emit(f Finding) {
+	if f.FirstSeen.IsZero() {
+		f.FirstSeen = time.Now()
+	}
+
+	e.mu.Lock()
+	switch e.format {
+	case FormatJSONL:
+		enc := json.NewEncoder(e.out)
+		if err := enc.Encode(f); err != nil {
+			logrus.Error(err)
+		}
+	case FormatText:
+		e.emitText(f)
+	default:
+		e.findings = append(e.findings, f)
+	}
+	listeners := append([]Listener(nil), e.listeners...)
+	e.mu.Unlock()
+
+	for _, l := range listeners {
+		l(f)
+	}
+}
+
+func (e *Emitter) emitText(f Finding) {
+	entry := logrus.WithFields(logrus.Fields{
+		"account":   f.Account,
+		"principal": f.Principal,
+		"resource":  f.Resource,
+		"rule_id":   f.RuleID,
+	})
+	msg := f.Evidence
+	if f.Remediation != "" {
+		msg = fmt.Sprintf("%s (remediation: %s)", msg, f.Remediation)
+	}
+	switch f.Severity {
+	case SeverityCritical, SeverityHigh:
+		entry.Error(msg)
+	case SeverityMedium:
+		entry.Warn(msg)
+	default:
+		entry.Info(msg)
+	}
+}
+
+// Close flushes any buffered findings. It is a no-op for text and jsonl,
+// which stream as they go.
+func (e *Emitter) Close() error {
+	switch e.format {
+	case FormatJSON:
+		enc := json.NewEncoder(e.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e.findings)
+	case FormatSARIF:
+		return json.NewEncoder(e.out).Encode(toSARIF(e.findings))
+	default:
+		return nil
+	}
+}
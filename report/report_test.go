@@ -0,0 +1,86 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEmitterJSONLStreamsEachFindingImmediately(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEmitter(FormatJSONL, &buf)
+
+	e.Emit(Finding{Account: "111111111111", RuleID: "stale-access-key", Severity: SeverityLow})
+	e.Emit(Finding{Account: "111111111111", RuleID: "admin-policy", Severity: SeverityHigh})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 JSONL lines written before Close, got %d: %q", len(lines), buf.String())
+	}
+	var first Finding
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("unmarshal first line: %v", err)
+	}
+	if first.RuleID != "stale-access-key" {
+		t.Fatalf("got rule_id %q, want stale-access-key", first.RuleID)
+	}
+}
+
+func TestEmitterJSONBuffersUntilClose(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEmitter(FormatJSON, &buf)
+	e.Emit(Finding{Account: "111111111111", RuleID: "stale-access-key", Severity: SeverityLow})
+
+	if buf.Len() != 0 {
+		t.Fatalf("want nothing written before Close in json mode, got %q", buf.String())
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	var got []Finding
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal closed output: %v", err)
+	}
+	if len(got) != 1 || got[0].RuleID != "stale-access-key" {
+		t.Fatalf("got %+v, want one stale-access-key finding", got)
+	}
+}
+
+func TestEmitterSubscribeNotifiesListenersRegardlessOfFormat(t *testing.T) {
+	e := NewEmitter(FormatJSON, &bytes.Buffer{})
+	var got []Finding
+	e.Subscribe(func(f Finding) { got = append(got, f) })
+
+	e.Emit(Finding{Account: "111111111111", RuleID: "admin-policy", Severity: SeverityHigh})
+
+	if len(got) != 1 || got[0].RuleID != "admin-policy" {
+		t.Fatalf("listener did not observe the emitted finding, got %+v", got)
+	}
+}
+
+func TestToSARIFEncodesEveryFindingAsAResult(t *testing.T) {
+	findings := []Finding{
+		{Account: "111111111111", Principal: "alice", RuleID: "admin-policy", Severity: SeverityHigh, Evidence: "user has full access policy attached", Remediation: "scope it down"},
+		{Account: "111111111111", Principal: "bob", RuleID: "stale-access-key", Severity: SeverityLow, Evidence: "key last used 400 days ago"},
+	}
+
+	sarif := toSARIF(findings)
+
+	if len(sarif.Runs) != 1 {
+		t.Fatalf("want exactly one run, got %d", len(sarif.Runs))
+	}
+	results := sarif.Runs[0].Results
+	if len(results) != len(findings) {
+		t.Fatalf("want %d results, got %d", len(findings), len(results))
+	}
+	if results[0].Level != "error" {
+		t.Fatalf("want high severity mapped to SARIF level \"error\", got %q", results[0].Level)
+	}
+	if !strings.Contains(results[0].Message.Text, "scope it down") {
+		t.Fatalf("result message missing remediation text: %+v", results[0].Message)
+	}
+	if results[0].Locations[0].LogicalLocations[0].FullyQualifiedName != "111111111111/alice" {
+		t.Fatalf("unexpected location name: %+v", results[0].Locations[0])
+	}
+}
@@ -0,0 +1,92 @@
+package report
+
+// Minimal SARIF 2.1.0 structures: just enough to carry a Finding as one
+// result per rule occurrence. Not a full SARIF implementation.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+func toSARIF(findings []Finding) sarifLog {
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		msg := f.Evidence
+		if f.Remediation != "" {
+			msg += " Remediation: " + f.Remediation
+		}
+		results = append(results, sarifResult{
+			RuleID:  f.RuleID,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: msg},
+			Locations: []sarifLocation{{
+				LogicalLocations: []sarifLogicalLocation{{
+					FullyQualifiedName: sarifLocationName(f),
+				}},
+			}},
+		})
+	}
+	return sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "hacks"}},
+			Results: results,
+		}},
+	}
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+func sarifLocationName(f Finding) string {
+	name := f.Account
+	if f.Principal != "" {
+		name += "/" + f.Principal
+	}
+	if f.Resource != "" {
+		name += "/" + f.Resource
+	}
+	return name
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
-	"github.com/haccer/subjack/subjack"
+	"github.com/omerxx/hacks/fingerprints"
+	"github.com/omerxx/hacks/notify"
+	"github.com/omerxx/hacks/report"
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -21,22 +27,95 @@ import (
 var verbose *bool
 var prefix string
 var now = time.Now()
+var findings *report.Emitter
+var concurrency int
+var retryMax int
+
+// defaultFingerprintsCache is where a -fingerprints-url feed (or its ETag
+// metadata) is cached between runs, and what `fingerprints update` refreshes.
+const defaultFingerprintsCache = "./fingerprints.json"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fingerprints" {
+		runFingerprintsCommand(os.Args[2:])
+		return
+	}
+
 	var profilesFlag = flag.String("profiles", "default", "An AWS CLI profile name, or comma-separated list for multiple")
+	var outputFlag = flag.String("output", "text", "Output format for findings: text, json, jsonl, or sarif")
+	var notifyConfigFlag = flag.String("notify-config", "", "Path to a YAML config declaring notification sinks (Slack, PagerDuty, webhook, SNS) for findings")
+	var fingerprintsURLFlag = flag.String("fingerprints-url", "", "URL of a remote fingerprints.json feed to fetch and cache locally, in addition to the built-in fingerprints")
+	var fingerprintsFileFlag = flag.String("fingerprints-file", defaultFingerprintsCache, "Local fingerprints.json to load in addition to the built-in fingerprints")
+	var concurrencyFlag = flag.Int("concurrency", 20, "Number of record sets to check concurrently per hosted zone")
+	var retryMaxFlag = flag.Int("retry-max", 10, "Max SDK retries per Route53 call, to survive Throttling on large accounts")
 	verbose = flag.Bool("verbose", false, "Notify all record sets including non vulnerable")
 	flag.Parse()
 
+	outputFormat, err := report.ParseFormat(*outputFlag)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	findings = report.NewEmitter(outputFormat, os.Stdout)
+	defer findings.Close()
+
+	if *notifyConfigFlag != "" {
+		cfg, err := notify.LoadConfig(*notifyConfigFlag)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		dispatcher, err := notify.NewDispatcher(cfg, *notifyConfigFlag+".seen.json")
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		findings.Subscribe(dispatcher.Dispatch)
+		defer func() {
+			if err := dispatcher.Save(); err != nil {
+				logrus.Error(err)
+			}
+		}()
+	}
+
+	if *fingerprintsURLFlag != "" {
+		fingerprints.Register(fingerprints.URLProvider{URL: *fingerprintsURLFlag, CachePath: *fingerprintsFileFlag})
+	} else {
+		fingerprints.Register(fingerprints.FileProvider{Path: *fingerprintsFileFlag})
+	}
+
+	concurrency = *concurrencyFlag
+	retryMax = *retryMaxFlag
 	var profiles = strings.Split(*profilesFlag, ",")
 	for _, profile := range profiles {
 		checkSubdomainTakeovers(profile)
 	}
 }
 
+// runFingerprintsCommand implements the `fingerprints update` subcommand,
+// which refreshes the local -fingerprints-url cache on demand (e.g. from a
+// cron job) instead of waiting for the next scan to pick up a stale feed.
+func runFingerprintsCommand(args []string) {
+	if len(args) == 0 || args[0] != "update" {
+		logrus.Fatal("usage: route53-subjack fingerprints update -fingerprints-url <url> [-fingerprints-file <path>]")
+	}
+
+	fs := flag.NewFlagSet("fingerprints update", flag.ExitOnError)
+	urlFlag := fs.String("fingerprints-url", "", "URL of the remote fingerprints.json feed to fetch")
+	fileFlag := fs.String("fingerprints-file", defaultFingerprintsCache, "Local path to cache the feed to")
+	fs.Parse(args[1:])
+
+	if *urlFlag == "" {
+		logrus.Fatal("-fingerprints-url is required")
+	}
+	if err := fingerprints.UpdateCache(*urlFlag, *fileFlag); err != nil {
+		logrus.Fatal(err)
+	}
+	logrus.Infof("fingerprints cache at %s is up to date", *fileFlag)
+}
+
 func checkSubdomainTakeovers(profile string) {
 	session, _ := session.NewSessionWithOptions(session.Options{
 		Profile:           profile,
 		SharedConfigState: session.SharedConfigEnable,
+		Config:            aws.Config{MaxRetries: aws.Int(retryMax)},
 	})
 
 	// 1. List hostedzones
@@ -56,7 +135,7 @@ func checkSubdomainTakeovers(profile string) {
 	for _, zone := range hostedZones {
 		if !(*zone.Config.PrivateZone) {
 			wg.Add(1)
-			go checkHostedZone(session, *zone.Id, log, &wg)
+			go checkHostedZone(session, *zone.Id, profile, log, &wg)
 		}
 	}
 	wg.Wait()
@@ -64,79 +143,151 @@ func checkSubdomainTakeovers(profile string) {
 
 func listHostedZones(session *session.Session) ([]*route53.HostedZone, error) {
 	svc := route53.New(session)
-	input := &route53.ListHostedZonesInput{}
-	hostedZones, err := svc.ListHostedZones(input)
+	var hostedZones []*route53.HostedZone
+	err := svc.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+		hostedZones = append(hostedZones, page.HostedZones...)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-	return hostedZones.HostedZones, nil
+	return hostedZones, nil
 }
 
-func checkHostedZone(session *session.Session, zoneID string, log *logrus.Entry, wg *sync.WaitGroup) {
+func checkHostedZone(session *session.Session, zoneID string, account string, log *logrus.Entry, wg *sync.WaitGroup) {
 	recordSets, _ := listRecordSets(session, zoneID)
 	recordSetLog := logrus.New().WithField("app", "1")
 	recordSetLog.Logger.SetLevel(logrus.DebugLevel)
 
-	var fingerprints []subjack.Fingerprints
-	config, _ := ioutil.ReadFile("./fingerprints.json")
-	json.Unmarshal(config, &fingerprints)
+	fps := fingerprints.Load()
 
+	sem := make(chan struct{}, concurrency)
 	var wg2 sync.WaitGroup
 	for _, subdomain := range recordSets {
 		// if *subdomain.Name == "." {
 		// 	log.Infof("Calling %s", *subdomain.Name)
 		wg2.Add(1)
-		go checkRecordSet(*subdomain.Name, recordSetLog, fingerprints, &wg2)
+		sem <- struct{}{}
+		go func(name string) {
+			defer func() { <-sem }()
+			checkRecordSet(name, account, recordSetLog, fps, &wg2)
+		}(*subdomain.Name)
 		// }
 	}
 	wg2.Wait()
 	wg.Done()
 }
 
-func checkRecordSet(subdomain string, log *logrus.Entry, fingerprints []subjack.Fingerprints, wg2 *sync.WaitGroup) {
+func checkRecordSet(subdomain string, account string, log *logrus.Entry, fps []fingerprints.Fingerprint, wg2 *sync.WaitGroup) {
 	trimmed := strings.TrimSuffix(subdomain, ".")
-	service := subjack.Identify(trimmed, false, false, 10, fingerprints)
+	service := identify(trimmed, fps)
 	if service != "" {
 		service = strings.ToLower(service)
-		log.Infof("%s is pointing to a vulnerable %s service.\n", trimmed, service)
+		findings.Emit(report.Finding{
+			Account:     account,
+			Resource:    trimmed,
+			RuleID:      "subdomain-takeover",
+			Severity:    report.SeverityHigh,
+			Evidence:    fmt.Sprintf("%s is pointing to a vulnerable %s service", trimmed, service),
+			Remediation: "remove the dangling DNS record or reclaim the resource it points to",
+		})
 	} else {
 		if *verbose {
-			log.Debugf(fmt.Sprintf("%s is ok\n", subdomain))
+			log.Debugf("%s is ok\n", subdomain)
 		}
 	}
 	wg2.Done()
 }
 
 func listRecordSets(session *session.Session, zoneID string) ([]*route53.ResourceRecordSet, error) {
-	var recordSets []*route53.ResourceRecordSet
 	var fileterdRecordSets []*route53.ResourceRecordSet
 	svc := route53.New(session)
 	input := &route53.ListResourceRecordSetsInput{
 		HostedZoneId: aws.String(zoneID),
 	}
-	result, err := svc.ListResourceRecordSets(input)
+	err := svc.ListResourceRecordSetsPages(input, func(page *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+		for _, record := range page.ResourceRecordSets {
+			if *record.Type == "CNAME" {
+				fileterdRecordSets = append(fileterdRecordSets, record)
+			}
+		}
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-	recordSets = result.ResourceRecordSets
-	isTruncated := *result.IsTruncated
-	for isTruncated {
-		input := &route53.ListResourceRecordSetsInput{
-			HostedZoneId:    aws.String(zoneID),
-			StartRecordName: aws.String(*result.NextRecordName),
-		}
-		result, err = svc.ListResourceRecordSets(input)
-		if err != nil {
-			return nil, err
+	return fileterdRecordSets, nil
+}
+
+// identifyTimeout bounds both the CNAME lookup and the HTTP GET identify
+// makes per record set, so one unresponsive subdomain can't stall a whole
+// hosted zone's worth of concurrent checks.
+const identifyTimeout = 10 * time.Second
+
+// identify reports the vulnerable service a subdomain's dangling CNAME
+// points at, or "" if none of fps match. It mirrors subjack's own matching
+// logic, since the vendored subjack package only exposes Process (a
+// self-contained CLI entry point that can't be pointed at our own fps), not
+// a reusable Identify function.
+func identify(subdomain string, fps []fingerprints.Fingerprint) string {
+	cname, err := resolveCNAME(subdomain)
+	if err != nil {
+		for _, fp := range fps {
+			if !fp.Nxdomain {
+				continue
+			}
+			for _, c := range fp.Cname {
+				if strings.Contains(cname, c) {
+					return fp.Service
+				}
+			}
 		}
-		recordSets = append(recordSets, result.ResourceRecordSets...)
-		isTruncated = *result.IsTruncated
+		return ""
 	}
 
-	for _, record := range recordSets {
-		if *record.Type == "CNAME" {
-			fileterdRecordSets = append(fileterdRecordSets, record)
+	body := httpGet(subdomain)
+	for _, fp := range fps {
+		if fp.Nxdomain {
+			continue
+		}
+		if strings.Contains(string(body), fp.Response) {
+			return fp.Service
 		}
 	}
-	return fileterdRecordSets, nil
+	return ""
+}
+
+// resolveCNAME returns subdomain's CNAME target, or an error (including on
+// NXDOMAIN) if it can't be resolved.
+func resolveCNAME(subdomain string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), identifyTimeout)
+	defer cancel()
+	cname, err := net.DefaultResolver.LookupCNAME(ctx, subdomain)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cname, "."), nil
+}
+
+var httpClient = &http.Client{
+	Timeout: identifyTimeout,
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+}
+
+// httpGet fetches subdomain over HTTP and returns the response body, or nil
+// on any error - a dangling CNAME with no HTTP server behind it is simply
+// "no body to match against", not a reason to abort the scan.
+func httpGet(subdomain string) []byte {
+	resp, err := httpClient.Get("http://" + subdomain)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil
+	}
+	return body
 }